@@ -0,0 +1,110 @@
+package readenv
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"text/tabwriter"
+)
+
+// FieldDoc documents a single env-tagged field, as discovered by Describe.
+type FieldDoc struct {
+	// Env is the environment variable name, including any envPrefix.
+	Env string
+	// Type is a short human-readable name for the field's type, e.g. "int",
+	// "duration" or "[]string".
+	Type string
+	// Default is the field's envDefault tag, if any.
+	Default string
+	// Required is true if the field carries envRequired:"true".
+	Required bool
+	// Description is drawn from the field's envDescription tag.
+	Description string
+}
+
+// Describe walks dest the same way ReadEnv does, and returns a FieldDoc for
+// every env-tagged field. It lets a binary generate its own --help output
+// from the same struct that ReadEnv consumes.
+//
+// The argument to Describe may be a struct or a pointer to a struct.
+func Describe(dest interface{}) ([]FieldDoc, error) {
+	v := reflect.ValueOf(dest)
+	for v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("readenv: dest should be a struct or pointer to struct, but was %v", reflect.TypeOf(dest))
+	}
+	var docs []FieldDoc
+	describeStruct(v.Type(), &docs, "")
+	return docs, nil
+}
+
+func describeStruct(t reflect.Type, docs *[]FieldDoc, envPrefix string) {
+	for i := 0; i < t.NumField(); i++ {
+		describeField(t.Field(i), docs, envPrefix)
+	}
+}
+
+func describeField(field reflect.StructField, docs *[]FieldDoc, envPrefix string) {
+	if envName, ok := field.Tag.Lookup("env"); ok {
+		*docs = append(*docs, FieldDoc{
+			Env:         envPrefix + envName,
+			Type:        describeType(field.Type),
+			Default:     field.Tag.Get("envDefault"),
+			Required:    field.Tag.Get("envRequired") == "true",
+			Description: field.Tag.Get("envDescription"),
+		})
+		return
+	}
+	childEnvPrefix := envPrefix
+	if p, ok := field.Tag.Lookup("envPrefix"); ok {
+		childEnvPrefix = envPrefix + p
+	}
+	t := field.Type
+	switch {
+	case t.Kind() == reflect.Struct:
+		describeStruct(t, docs, childEnvPrefix)
+	case t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Struct:
+		describeStruct(t.Elem(), docs, childEnvPrefix)
+	}
+}
+
+// describeType returns a short human-readable name for t.
+func describeType(t reflect.Type) string {
+	switch {
+	case isDuration(t):
+		return "duration"
+	case isTime(t):
+		return "time"
+	case isLocation(t):
+		return "location"
+	case isStringStringMap(t):
+		return "map[string]string"
+	case isStringIntMap(t):
+		return "map[string]int"
+	case t.Kind() == reflect.Slice:
+		return "[]" + describeType(t.Elem())
+	default:
+		return t.String()
+	}
+}
+
+// PrintUsage writes an aligned table describing dest's env-tagged fields to
+// w, suitable for use as --help output.
+func PrintUsage(w io.Writer, dest interface{}) error {
+	docs, err := Describe(dest)
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ENV\tTYPE\tREQUIRED\tDEFAULT\tDESCRIPTION")
+	for _, d := range docs {
+		required := ""
+		if d.Required {
+			required = "yes"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", d.Env, d.Type, required, d.Default, d.Description)
+	}
+	return tw.Flush()
+}
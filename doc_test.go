@@ -0,0 +1,63 @@
+package readenv
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDescribe(t *testing.T) {
+	type DBConfig struct {
+		Host string `env:"HOST" envDescription:"database host"`
+	}
+	type testOpts struct {
+		DB      DBConfig      `envPrefix:"DB_"`
+		Port    int           `env:"PORT" envDefault:"8080" envDescription:"listen port"`
+		APIKey  string        `env:"API_KEY" envRequired:"true"`
+		Timeout time.Duration `env:"TIMEOUT"`
+	}
+	docs, err := Describe(&testOpts{})
+	if err != nil {
+		t.Fatalf("Describe failed: %v", err)
+	}
+	if len(docs) != 4 {
+		t.Fatalf("expected 4 field docs, got %d: %+v", len(docs), docs)
+	}
+	byEnv := make(map[string]FieldDoc)
+	for _, d := range docs {
+		byEnv[d.Env] = d
+	}
+	host, ok := byEnv["DB_HOST"]
+	if !ok {
+		t.Fatalf("expected a doc for DB_HOST, got %+v", docs)
+	}
+	if host.Description != "database host" {
+		t.Errorf("DB_HOST description should have been 'database host' but was %q", host.Description)
+	}
+	port, ok := byEnv["PORT"]
+	if !ok || port.Default != "8080" {
+		t.Errorf("PORT should have had default '8080', got %+v", port)
+	}
+	apiKey, ok := byEnv["API_KEY"]
+	if !ok || !apiKey.Required {
+		t.Errorf("API_KEY should have been required, got %+v", apiKey)
+	}
+	timeout, ok := byEnv["TIMEOUT"]
+	if !ok || timeout.Type != "duration" {
+		t.Errorf("TIMEOUT should have had type 'duration', got %+v", timeout)
+	}
+}
+
+func TestPrintUsage(t *testing.T) {
+	type testOpts struct {
+		Port int `env:"PORT" envDefault:"8080" envDescription:"listen port"`
+	}
+	var buf strings.Builder
+	if err := PrintUsage(&buf, &testOpts{}); err != nil {
+		t.Fatalf("PrintUsage failed: %v", err)
+	}
+	out := buf.String()
+	if !strings.Contains(out, "PORT") || !strings.Contains(out, "8080") || !strings.Contains(out, "listen port") {
+		t.Errorf("usage output missing expected content:\n%s", out)
+	}
+}
@@ -0,0 +1,198 @@
+package readenv
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MarshalEnv walks src the same way ReadEnv does, and returns a map from each
+// tagged field's environment variable name to the string form of its current
+// value. It is the inverse of ReadEnv, and is useful in tests that want to
+// synthesize an environment, or in tooling that generates a .env template
+// from a config struct.
+//
+// The argument to MarshalEnv may be a struct or a pointer to a struct.
+func MarshalEnv(src interface{}) (map[string]string, error) {
+	v := reflect.ValueOf(src)
+	for v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("readenv: src should be a struct or pointer to struct, but was %v", reflect.TypeOf(src))
+	}
+	if !v.CanAddr() {
+		// A struct passed by value is copied so that its fields are
+		// addressable, which the Getter, Setter and TextMarshaler checks need.
+		addressable := reflect.New(v.Type()).Elem()
+		addressable.Set(v)
+		v = addressable
+	}
+	out := make(map[string]string)
+	if err := marshalStruct(v, out, ""); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func marshalStruct(v reflect.Value, out map[string]string, envPrefix string) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		fieldValue := v.Field(i)
+		if !fieldValue.CanInterface() {
+			if _, tagged := field.Tag.Lookup("env"); tagged {
+				return fmt.Errorf("readenv: could not marshal %s: field is not writeable", field.Name)
+			}
+			// Untagged unexported fields (a mutex, a cache, a logger) aren't
+			// part of the config surface readenv manages, so leave them out
+			// instead of failing the whole struct.
+			continue
+		}
+		if err := marshalField(fieldValue, field, out, envPrefix); err != nil {
+			return fmt.Errorf("readenv: could not marshal %s: %v", field.Name, err)
+		}
+	}
+	return nil
+}
+
+func marshalField(val reflect.Value, field reflect.StructField, out map[string]string, envPrefix string) error {
+	if envName, ok := field.Tag.Lookup("env"); ok {
+		envName = envPrefix + envName
+		value, err := marshalValue(val, field)
+		if err != nil {
+			return err
+		}
+		out[envName] = value
+		return nil
+	}
+	childEnvPrefix := envPrefix
+	if p, ok := field.Tag.Lookup("envPrefix"); ok {
+		childEnvPrefix = envPrefix + p
+	}
+	switch {
+	case field.Type.Kind() == reflect.Struct:
+		return marshalStruct(val, out, childEnvPrefix)
+	case field.Type.Kind() == reflect.Ptr && field.Type.Elem().Kind() == reflect.Struct:
+		if val.IsNil() {
+			return nil
+		}
+		return marshalStruct(val.Elem(), out, childEnvPrefix)
+	}
+	return nil
+}
+
+func marshalValue(val reflect.Value, field reflect.StructField) (string, error) {
+	if getter, ok := getterFor(val); ok {
+		return getter.MarshalEnv()
+	}
+	if _, ok := setterFor(val); ok {
+		if marshaler, ok := textMarshalerFor(val); ok {
+			b, err := marshaler.MarshalText()
+			if err != nil {
+				return "", err
+			}
+			return string(b), nil
+		}
+		return "", fmt.Errorf("%v implements Setter but not Getter or encoding.TextMarshaler, so it cannot be marshaled", val.Type())
+	}
+	t := field.Type
+	switch {
+	case isDuration(t):
+		return val.Interface().(time.Duration).String(), nil
+	case isTime(t):
+		layout := field.Tag.Get("envLayout")
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		return val.Interface().(time.Time).Format(layout), nil
+	case isLocation(t):
+		loc, _ := val.Interface().(*time.Location)
+		if loc == nil {
+			return "", nil
+		}
+		return loc.String(), nil
+	case isStringStringMap(t):
+		return marshalMap(val, func(v reflect.Value) string { return v.String() }), nil
+	case isStringIntMap(t):
+		return marshalMap(val, func(v reflect.Value) string { return strconv.FormatInt(v.Int(), 10) }), nil
+	case t.Kind() == reflect.Slice:
+		sep := field.Tag.Get("envSeparator")
+		if sep == "" {
+			sep = ","
+		}
+		return marshalSlice(val, sep)
+	case isInt(t):
+		return strconv.FormatInt(val.Int(), 10), nil
+	case isFloat(t):
+		return strconv.FormatFloat(val.Float(), 'f', -1, 64), nil
+	case isBool(t):
+		return formatBool(val.Bool()), nil
+	case isString(t):
+		return val.String(), nil
+	}
+	if marshaler, ok := textMarshalerFor(val); ok {
+		b, err := marshaler.MarshalText()
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	}
+	return "", fmt.Errorf("cannot marshal field of type %v", t)
+}
+
+func marshalSlice(val reflect.Value, sep string) (string, error) {
+	elemType := val.Type().Elem()
+	parts := make([]string, val.Len())
+	for i := 0; i < val.Len(); i++ {
+		s, err := formatPrimitive(val.Index(i), elemType)
+		if err != nil {
+			return "", err
+		}
+		parts[i] = s
+	}
+	return strings.Join(parts, sep), nil
+}
+
+// formatPrimitive is the inverse of setPrimitive.
+func formatPrimitive(elem reflect.Value, t reflect.Type) (string, error) {
+	switch {
+	case isInt(t):
+		return strconv.FormatInt(elem.Int(), 10), nil
+	case isFloat(t):
+		return strconv.FormatFloat(elem.Float(), 'f', -1, 64), nil
+	case isBool(t):
+		return formatBool(elem.Bool()), nil
+	case isString(t):
+		return elem.String(), nil
+	default:
+		return "", fmt.Errorf("unsupported element type %v", t)
+	}
+}
+
+// formatBool is the inverse of parseBool: true is written as "true" and false
+// as "0", because parseBool reads "false" as true.
+func formatBool(b bool) string {
+	if b {
+		return "true"
+	}
+	return "0"
+}
+
+// marshalMap formats val (a map[string]string or map[string]T) as
+// comma-separated key=value pairs, sorted by key for deterministic output.
+func marshalMap(val reflect.Value, formatValue func(reflect.Value) string) string {
+	keys := make([]string, 0, val.Len())
+	for _, k := range val.MapKeys() {
+		keys = append(keys, k.String())
+	}
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = k + "=" + formatValue(val.MapIndex(reflect.ValueOf(k)))
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,152 @@
+package readenv
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMarshalEnv(t *testing.T) {
+	type DBConfig struct {
+		Host string `env:"HOST"`
+		Port int    `env:"PORT"`
+	}
+	type testOpts struct {
+		DB      DBConfig      `envPrefix:"DB_"`
+		Debug   bool          `env:"DEBUG"`
+		Timeout time.Duration `env:"TIMEOUT"`
+		Tags    []string      `env:"TAGS" envSeparator:":"`
+	}
+	opts := testOpts{
+		DB:      DBConfig{Host: "localhost", Port: 5432},
+		Debug:   true,
+		Timeout: 5 * time.Second,
+		Tags:    []string{"a", "b", "c"},
+	}
+	out, err := MarshalEnv(&opts)
+	if err != nil {
+		t.Fatalf("MarshalEnv failed: %v", err)
+	}
+	want := map[string]string{
+		"DB_HOST": "localhost",
+		"DB_PORT": "5432",
+		"DEBUG":   "true",
+		"TIMEOUT": "5s",
+		"TAGS":    "a:b:c",
+	}
+	for k, v := range want {
+		if out[k] != v {
+			t.Errorf("out[%q] should have been %q but was %q", k, v, out[k])
+		}
+	}
+}
+
+func TestMarshalEnvRoundTrip(t *testing.T) {
+	type testOpts struct {
+		Host string `env:"HOST"`
+		Port int    `env:"PORT"`
+	}
+	original := testOpts{Host: "example.com", Port: 8080}
+	out, err := MarshalEnv(&original)
+	if err != nil {
+		t.Fatalf("MarshalEnv failed: %v", err)
+	}
+	roundTripped := &testOpts{}
+	if err := ReadEnvFrom(roundTripped, MapSource(out)); err != nil {
+		t.Fatalf("ReadEnvFrom failed: %v", err)
+	}
+	if *roundTripped != original {
+		t.Errorf("round trip should have produced %+v but got %+v", original, *roundTripped)
+	}
+}
+
+func TestMarshalEnvNotAStruct(t *testing.T) {
+	if _, err := MarshalEnv("not a struct"); err == nil {
+		t.Error("marshaling a non-struct should have returned an error")
+	}
+}
+
+func TestMarshalEnvSetterWithoutGetter(t *testing.T) {
+	type testOpts struct {
+		Values csvInts `env:"VALUES"`
+	}
+	opts := testOpts{Values: csvInts{1, 2, 3}}
+	if _, err := MarshalEnv(&opts); err == nil {
+		t.Error("marshaling a Setter-only type should have returned an error")
+	}
+}
+
+type pipeInts []int
+
+func (p *pipeInts) UnmarshalEnv(value string) error {
+	*p = nil
+	for _, part := range strings.Split(value, "|") {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return err
+		}
+		*p = append(*p, n)
+	}
+	return nil
+}
+
+func (p pipeInts) MarshalText() ([]byte, error) {
+	parts := make([]string, len(p))
+	for i, n := range p {
+		parts[i] = strconv.Itoa(n)
+	}
+	return []byte(strings.Join(parts, "|")), nil
+}
+
+func TestMarshalEnvSetterWithTextMarshalerFallback(t *testing.T) {
+	type testOpts struct {
+		Values pipeInts `env:"VALUES"`
+	}
+	original := testOpts{Values: pipeInts{1, 2, 3}}
+	out, err := MarshalEnv(&original)
+	if err != nil {
+		t.Fatalf("MarshalEnv failed: %v", err)
+	}
+	if out["VALUES"] != "1|2|3" {
+		t.Errorf("out[\"VALUES\"] should have been \"1|2|3\" but was %q", out["VALUES"])
+	}
+	roundTripped := &testOpts{}
+	if err := ReadEnvFrom(roundTripped, MapSource(out)); err != nil {
+		t.Fatalf("ReadEnvFrom failed: %v", err)
+	}
+	if len(roundTripped.Values) != len(original.Values) {
+		t.Fatalf("round trip should have produced %v but got %v", original.Values, roundTripped.Values)
+	}
+	for i := range original.Values {
+		if roundTripped.Values[i] != original.Values[i] {
+			t.Errorf("round trip should have produced %v but got %v", original.Values, roundTripped.Values)
+		}
+	}
+}
+
+func TestMarshalEnvUnexportedField(t *testing.T) {
+	type testOpts struct {
+		Host       string `env:"HOST"`
+		unexported string `env:"SECRET"`
+	}
+	opts := testOpts{Host: "localhost", unexported: "hidden"}
+	if _, err := MarshalEnv(&opts); err == nil {
+		t.Error("marshaling a struct with an unexported tagged field should have returned an error")
+	}
+}
+
+func TestMarshalEnvUntaggedUnexportedFieldIgnored(t *testing.T) {
+	type testOpts struct {
+		Host  string `env:"HOST"`
+		cache map[string]string
+	}
+	opts := testOpts{Host: "localhost", cache: map[string]string{"a": "b"}}
+	out, err := MarshalEnv(&opts)
+	if err != nil {
+		t.Fatalf("MarshalEnv failed: %v", err)
+	}
+	if out["HOST"] != "localhost" {
+		t.Errorf("out[\"HOST\"] should have been \"localhost\" but was %q", out["HOST"])
+	}
+}
@@ -4,83 +4,424 @@
 // To use readenv, simply add tags to your struct containing the environment
 // variable the field should be read from. For example:
 //
-//     type options struct {
-//       Port int `env:"PORT"`
-//     }
+//	type options struct {
+//	  Port int `env:"PORT"`
+//	}
 //
 // Note that the field must be exported so that it can be writeable.
+//
+// Structs can be composed by nesting struct (or pointer-to-struct) fields. Each
+// nested struct is walked the same way as the top-level one, which makes it
+// possible to build up realistic multi-service configs without flattening
+// every variable into a single struct:
+//
+//	type App struct {
+//	  DB    DBConfig    `envPrefix:"DB_"`
+//	  Redis RedisConfig `envPrefix:"REDIS_"`
+//	}
+//
+// The optional envPrefix tag is prepended to every env tag found while reading
+// that field, so a `HOST` tag inside DBConfig is read from DB_HOST.
+//
+// Beyond the primitive types, readenv understands time.Duration (parsed with
+// time.ParseDuration), time.Time (parsed as RFC3339 by default, or with the
+// layout given by an envLayout tag), *time.Location (via time.LoadLocation),
+// slices of any supported primitive (split on "," by default, or on the
+// separator given by an envSeparator tag) and map[string]string /
+// map[string]int (comma-separated key=value pairs). Any type implementing
+// Setter, and as a fallback encoding.TextUnmarshaler, is also supported. A
+// type implementing Setter must also implement Getter (or
+// encoding.TextMarshaler) if it needs to round-trip through MarshalEnv.
+//
+// A field may also carry an envDefault tag, whose value is used whenever the
+// environment variable is unset, and an envRequired:"true" tag, which turns a
+// missing variable (with no default) into an error. Fields with neither tag
+// are left at their zero value when the variable is unset, matching the
+// behavior of an ordinary Go struct literal.
+//
+// ReadEnv always reads from the process environment. To read from somewhere
+// else - a map built up in a test, or a parsed .env file - use ReadEnvFrom
+// with a Source. MarshalEnv provides the inverse operation, turning a struct
+// back into a map of environment variable names to values.
+//
+// A field tagged envExpand:"true" has its raw value passed through os.Expand
+// before parsing, so "${OTHER_VAR}" and "$OTHER_VAR" references are resolved
+// against the same Source. A field tagged envFile:"true" treats its
+// environment variable's value as a path to a file to read the real value
+// from, and - independently of that tag - a <NAME>_FILE variable always
+// overrides <NAME> the same way, matching the Docker/Kubernetes convention
+// for mounting secrets as files.
 package readenv
 
 import (
+	"encoding"
 	"fmt"
 	"os"
 	"reflect"
 	"strconv"
 	"strings"
+	"time"
 )
 
+// Setter is implemented by types that know how to parse their own value out of
+// a raw environment variable string. If a field's type implements Setter,
+// readenv calls UnmarshalEnv instead of using its built-in parsing.
+type Setter interface {
+	UnmarshalEnv(value string) error
+}
+
+// Getter is the inverse of Setter: it is implemented by types that know how
+// to format themselves as a raw environment variable string. If a field's
+// type implements Getter, MarshalEnv calls MarshalEnv instead of using its
+// built-in formatting.
+type Getter interface {
+	MarshalEnv() (string, error)
+}
+
+var (
+	setterType          = reflect.TypeOf((*Setter)(nil)).Elem()
+	getterType          = reflect.TypeOf((*Getter)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// FieldError describes why a single struct field could not be populated from
+// its environment variable.
+type FieldError struct {
+	// Field is the dotted path to the struct field, e.g. "DB.Host".
+	Field string
+	// Env is the environment variable that was being read, if the field had
+	// reached that point (it may be empty for structural errors such as an
+	// unexported field).
+	Env string
+	// Err is the underlying cause.
+	Err error
+}
+
+func (e *FieldError) Error() string {
+	if e.Env == "" {
+		return fmt.Sprintf("%s: %v", e.Field, e.Err)
+	}
+	return fmt.Sprintf("%s (%s): %v", e.Field, e.Env, e.Err)
+}
+
+// Unwrap returns the underlying cause, so that errors.Is and errors.As can see
+// through a FieldError.
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// ParseError is returned by ReadEnv when one or more fields could not be
+// populated. It aggregates every failure rather than stopping at the first
+// one, so a caller can report every misconfigured variable at once.
+type ParseError struct {
+	Errors []*FieldError
+}
+
+func (e *ParseError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, fe := range e.Errors {
+		msgs[i] = fe.Error()
+	}
+	return fmt.Sprintf("readenv: %d error(s): %s", len(e.Errors), strings.Join(msgs, "; "))
+}
+
+// Unwrap gives access to each individual FieldError via errors.Is and
+// errors.As.
+func (e *ParseError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, fe := range e.Errors {
+		errs[i] = fe
+	}
+	return errs
+}
+
 // ReadEnv reads environment variables into the provided struct pointer. If
-// there are any problems reading or parsing the environment variables, an error
-// is returned.
+// there are any problems reading or parsing the environment variables, a
+// *ParseError is returned describing every field that failed.
 //
 // The argument to ReadEnv must be a pointer to a struct. If any other value is
 // passed, an error will be returned.
 //
+// An optional prefix may be given, which is prepended to every env tag in the
+// struct (including tags found in nested structs), in the same way an
+// envPrefix tag is.
+//
 // Some special values are recognized when parsing environment variables into
 // boolean fields: if the environment variable is set to "no", "off", "0", or is
 // empty, the bool will be set to false. Any other value in the environment will
 // set it to true.
-func ReadEnv(dest interface{}) error {
+func ReadEnv(dest interface{}, prefix ...string) error {
+	return ReadEnvFrom(dest, OSSource{}, prefix...)
+}
+
+// ReadEnvFrom behaves like ReadEnv, but reads from src instead of the process
+// environment. This is useful in tests, or for reading configuration from
+// somewhere other than the OS environment, such as a parsed .env file.
+func ReadEnvFrom(dest interface{}, src Source, prefix ...string) error {
 	v := reflect.ValueOf(dest)
 	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
 		return fmt.Errorf("readenv: dest should be pointer to struct, but was %v", v.Type())
 	}
-	v = v.Elem()
+	p := ""
+	if len(prefix) > 0 {
+		p = prefix[0]
+	}
+	if errs := readStruct(v.Elem(), src, p, ""); len(errs) > 0 {
+		return &ParseError{Errors: errs}
+	}
+	return nil
+}
+
+func readStruct(v reflect.Value, src Source, envPrefix, fieldPath string) []*FieldError {
+	var errs []*FieldError
 	t := v.Type()
 	for i := 0; i < t.NumField(); i++ {
 		field := t.Field(i)
 		fieldValue := v.Field(i)
-		if err := readField(fieldValue, field); err != nil {
-			return fmt.Errorf("readenv: could not set %s: %v", field.Name, err)
-		}
+		errs = append(errs, readField(fieldValue, field, src, envPrefix, fieldPath+field.Name)...)
 	}
-	return nil
+	return errs
 }
 
-func readField(val reflect.Value, field reflect.StructField) error {
+func readField(val reflect.Value, field reflect.StructField, src Source, envPrefix, fieldName string) []*FieldError {
+	if envName, ok := field.Tag.Lookup("env"); ok {
+		if !val.CanSet() {
+			return []*FieldError{{Field: fieldName, Err: fmt.Errorf("field is not writeable")}}
+		}
+		envName = envPrefix + envName
+		if err := readTaggedField(val, field, src, envName); err != nil {
+			return []*FieldError{{Field: fieldName, Env: envName, Err: err}}
+		}
+		return nil
+	}
 	if !val.CanSet() {
-		return fmt.Errorf("field is not writeable")
+		// Untagged unexported fields (a mutex, a cache, a logger) aren't part
+		// of the config surface readenv manages, so leave them alone instead
+		// of failing the whole struct.
+		return nil
 	}
-	if envName, ok := field.Tag.Lookup("env"); ok {
-		if isInt(field.Type) {
-			if err := readEnvInt(val, envName); err != nil {
-				return err
+	childEnvPrefix := envPrefix
+	if p, ok := field.Tag.Lookup("envPrefix"); ok {
+		childEnvPrefix = envPrefix + p
+	}
+	switch {
+	case field.Type.Kind() == reflect.Struct:
+		return readStruct(val, src, childEnvPrefix, fieldName+".")
+	case field.Type.Kind() == reflect.Ptr && field.Type.Elem().Kind() == reflect.Struct:
+		if val.IsNil() {
+			if !anyFieldPresent(field.Type.Elem(), src, childEnvPrefix) {
+				// None of this section's env vars were set, so leave the
+				// pointer nil rather than forcing an optional config section
+				// (and its envRequired fields) into existence.
+				return nil
 			}
-		} else if isString(field.Type) {
-			if err := readEnvString(val, envName); err != nil {
-				return err
+			val.Set(reflect.New(field.Type.Elem()))
+		}
+		return readStruct(val.Elem(), src, childEnvPrefix, fieldName+".")
+	}
+	return nil
+}
+
+// anyFieldPresent reports whether any env-tagged field reachable from t
+// (recursing into nested structs and pointer-to-structs) would resolve to a
+// value - either because its variable (or its <NAME>_FILE companion) is set
+// in src, or because it carries an envDefault tag. It never allocates or
+// mutates anything; it only decides whether an optional pointer-to-struct
+// field is worth allocating.
+func anyFieldPresent(t reflect.Type, src Source, envPrefix string) bool {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if envName, ok := field.Tag.Lookup("env"); ok {
+			envName = envPrefix + envName
+			if _, ok := lookupTrimmed(src, envName); ok {
+				return true
 			}
-		} else if isFloat(field.Type) {
-			if err := readEnvFloat(val, envName); err != nil {
-				return err
+			if _, ok := lookupTrimmed(src, envName+"_FILE"); ok {
+				return true
 			}
-		} else if isBool(field.Type) {
-			readEnvBool(val, envName)
+			if _, ok := field.Tag.Lookup("envDefault"); ok {
+				return true
+			}
+			continue
+		}
+		childEnvPrefix := envPrefix
+		if p, ok := field.Tag.Lookup("envPrefix"); ok {
+			childEnvPrefix = envPrefix + p
+		}
+		switch {
+		case field.Type.Kind() == reflect.Struct:
+			if anyFieldPresent(field.Type, src, childEnvPrefix) {
+				return true
+			}
+		case field.Type.Kind() == reflect.Ptr && field.Type.Elem().Kind() == reflect.Struct:
+			if anyFieldPresent(field.Type.Elem(), src, childEnvPrefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// readTaggedField resolves the environment variable envName from src -
+// honoring envDefault and envRequired - and, if a value was found, parses it
+// into val according to val's type and any relevant tags on field.
+func readTaggedField(val reflect.Value, field reflect.StructField, src Source, envName string) error {
+	value, present, err := resolveEnvValue(field, src, envName)
+	if err != nil {
+		return err
+	}
+	if !present {
+		return nil
+	}
+	return setValue(val, field, envName, value)
+}
+
+// resolveEnvValue looks up envName in src - honoring the <NAME>_FILE and
+// envFile file-indirection conventions and an envExpand tag - falling back to
+// an envDefault tag if the variable is unset. If neither is available and the
+// field carries envRequired:"true", an error is returned; otherwise the field
+// is left untouched (present is false).
+func resolveEnvValue(field reflect.StructField, src Source, envName string) (value string, present bool, err error) {
+	if filePath, ok := lookupTrimmed(src, envName+"_FILE"); ok {
+		content, ferr := readValueFile(filePath)
+		if ferr != nil {
+			return "", false, fmt.Errorf("%s_FILE: %v", envName, ferr)
+		}
+		return expandValue(field, src, content), true, nil
+	}
+	if v, ok := lookupTrimmed(src, envName); ok {
+		if field.Tag.Get("envFile") == "true" {
+			content, ferr := readValueFile(v)
+			if ferr != nil {
+				return "", false, fmt.Errorf("%s: %v", envName, ferr)
+			}
+			return expandValue(field, src, content), true, nil
+		}
+		return expandValue(field, src, v), true, nil
+	}
+	if def, ok := field.Tag.Lookup("envDefault"); ok {
+		return expandValue(field, src, def), true, nil
+	}
+	if field.Tag.Get("envRequired") == "true" {
+		return "", false, fmt.Errorf("%s is not set", envName)
+	}
+	return "", false, nil
+}
+
+// lookupTrimmed looks up name in src, treating an unset or all-whitespace
+// value the same as absent.
+func lookupTrimmed(src Source, name string) (string, bool) {
+	v, ok := src.Lookup(name)
+	return v, ok && strings.TrimSpace(v) != ""
+}
+
+// expandValue applies os.Expand to value, resolving "${VAR}"/"$VAR"
+// references against src, if field is tagged envExpand:"true".
+func expandValue(field reflect.StructField, src Source, value string) string {
+	if field.Tag.Get("envExpand") != "true" {
+		return value
+	}
+	return os.Expand(value, func(key string) string {
+		v, _ := src.Lookup(key)
+		return v
+	})
+}
+
+// readValueFile reads the file at path, trimming all trailing "\r" and "\n"
+// characters to play nicely with files written by `echo` rather than `printf`.
+func readValueFile(path string) (string, error) {
+	b, err := os.ReadFile(strings.TrimSpace(path))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(b), "\r\n"), nil
+}
+
+// setValue parses value into val according to val's type and any relevant
+// tags on field.
+func setValue(val reflect.Value, field reflect.StructField, envName, value string) error {
+	if setter, ok := setterFor(val); ok {
+		return setter.UnmarshalEnv(value)
+	}
+	t := field.Type
+	switch {
+	case isDuration(t):
+		return readEnvDuration(val, envName, value)
+	case isTime(t):
+		layout := field.Tag.Get("envLayout")
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		return readEnvTime(val, envName, value, layout)
+	case isLocation(t):
+		return readEnvLocation(val, envName, value)
+	case isStringStringMap(t):
+		return readEnvMapStringString(val, envName, value)
+	case isStringIntMap(t):
+		return readEnvMapStringInt(val, envName, value)
+	case t.Kind() == reflect.Slice:
+		sep := field.Tag.Get("envSeparator")
+		if sep == "" {
+			sep = ","
 		}
+		return readEnvSlice(val, envName, value, sep)
+	case isInt(t):
+		return readEnvInt(val, envName, value)
+	case isString(t):
+		return readEnvString(val, value)
+	case isFloat(t):
+		return readEnvFloat(val, envName, value)
+	case isBool(t):
+		readEnvBool(val, value)
+		return nil
+	}
+	if unmarshaler, ok := textUnmarshalerFor(val); ok {
+		return unmarshaler.UnmarshalText([]byte(value))
 	}
 	return nil
 }
 
+// setterFor returns val's address as a Setter, if it implements one.
+func setterFor(val reflect.Value) (Setter, bool) {
+	if !val.CanAddr() || !val.Addr().Type().Implements(setterType) {
+		return nil, false
+	}
+	return val.Addr().Interface().(Setter), true
+}
+
+// getterFor returns val's address as a Getter, if it implements one.
+func getterFor(val reflect.Value) (Getter, bool) {
+	if !val.CanAddr() || !val.Addr().Type().Implements(getterType) {
+		return nil, false
+	}
+	return val.Addr().Interface().(Getter), true
+}
+
+// textUnmarshalerFor returns val's address as an encoding.TextUnmarshaler, if
+// it implements one.
+func textUnmarshalerFor(val reflect.Value) (encoding.TextUnmarshaler, bool) {
+	if !val.CanAddr() || !val.Addr().Type().Implements(textUnmarshalerType) {
+		return nil, false
+	}
+	return val.Addr().Interface().(encoding.TextUnmarshaler), true
+}
+
+// textMarshalerFor returns val's address as an encoding.TextMarshaler, if it
+// implements one.
+func textMarshalerFor(val reflect.Value) (encoding.TextMarshaler, bool) {
+	if !val.CanAddr() || !val.Addr().Type().Implements(textMarshalerType) {
+		return nil, false
+	}
+	return val.Addr().Interface().(encoding.TextMarshaler), true
+}
+
 func isString(t reflect.Type) bool {
 	return t == reflect.TypeOf("")
 }
 
-func readEnvString(field reflect.Value, name string) error {
-	value := strings.TrimSpace(os.Getenv(name))
-	if value == "" {
-		return fmt.Errorf("%s is not set", name)
-	}
-	field.SetString(value)
+func readEnvString(field reflect.Value, value string) error {
+	field.SetString(strings.TrimSpace(value))
 	return nil
 }
 
@@ -92,12 +433,12 @@ func isInt(t reflect.Type) bool {
 		t == reflect.TypeOf(int64(0)))
 }
 
-func readEnvInt(field reflect.Value, name string) error {
-	value, err := strconv.Atoi(os.Getenv(name))
+func readEnvInt(field reflect.Value, name, value string) error {
+	n, err := strconv.Atoi(value)
 	if err != nil {
 		return fmt.Errorf("%s is not a number: %v", name, err)
 	}
-	field.SetInt(int64(value))
+	field.SetInt(int64(n))
 	return nil
 }
 
@@ -106,12 +447,12 @@ func isFloat(t reflect.Type) bool {
 		t == reflect.TypeOf(float64(0)))
 }
 
-func readEnvFloat(field reflect.Value, name string) error {
-	value, err := strconv.ParseFloat(os.Getenv(name), 64)
+func readEnvFloat(field reflect.Value, name, value string) error {
+	f, err := strconv.ParseFloat(value, 64)
 	if err != nil {
 		return fmt.Errorf("%s is not a float: %v", name, err)
 	}
-	field.SetFloat(value)
+	field.SetFloat(f)
 	return nil
 }
 
@@ -119,11 +460,138 @@ func isBool(t reflect.Type) bool {
 	return t == reflect.TypeOf(false)
 }
 
-func readEnvBool(field reflect.Value, name string) {
-	v := strings.ToLower(strings.TrimSpace(os.Getenv(name)))
-	if v == "" || v == "no" || v == "off" || v == "0" {
-		field.SetBool(false)
-	} else {
-		field.SetBool(true)
+func readEnvBool(field reflect.Value, value string) {
+	field.SetBool(parseBool(value))
+}
+
+// parseBool recognizes "no", "off", "0", and the empty string as false, and
+// everything else as true.
+func parseBool(value string) bool {
+	v := strings.ToLower(strings.TrimSpace(value))
+	return !(v == "" || v == "no" || v == "off" || v == "0")
+}
+
+func isDuration(t reflect.Type) bool {
+	return t == reflect.TypeOf(time.Duration(0))
+}
+
+func readEnvDuration(field reflect.Value, name, value string) error {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("%s is not a duration: %v", name, err)
+	}
+	field.Set(reflect.ValueOf(d))
+	return nil
+}
+
+func isTime(t reflect.Type) bool {
+	return t == reflect.TypeOf(time.Time{})
+}
+
+func readEnvTime(field reflect.Value, name, value, layout string) error {
+	parsed, err := time.Parse(layout, value)
+	if err != nil {
+		return fmt.Errorf("%s is not a valid time: %v", name, err)
+	}
+	field.Set(reflect.ValueOf(parsed))
+	return nil
+}
+
+func isLocation(t reflect.Type) bool {
+	return t == reflect.TypeOf(&time.Location{})
+}
+
+func readEnvLocation(field reflect.Value, name, value string) error {
+	loc, err := time.LoadLocation(value)
+	if err != nil {
+		return fmt.Errorf("%s is not a valid location: %v", name, err)
+	}
+	field.Set(reflect.ValueOf(loc))
+	return nil
+}
+
+func readEnvSlice(field reflect.Value, name, value, sep string) error {
+	parts := strings.Split(value, sep)
+	elemType := field.Type().Elem()
+	slice := reflect.MakeSlice(field.Type(), len(parts), len(parts))
+	for i, part := range parts {
+		if err := setPrimitive(slice.Index(i), elemType, strings.TrimSpace(part)); err != nil {
+			return fmt.Errorf("%s: element %d: %v", name, i, err)
+		}
+	}
+	field.Set(slice)
+	return nil
+}
+
+// setPrimitive parses raw into elem, whose type must be one of the primitive
+// types readenv understands.
+func setPrimitive(elem reflect.Value, t reflect.Type, raw string) error {
+	switch {
+	case isInt(t):
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number: %v", raw, err)
+		}
+		elem.SetInt(n)
+	case isFloat(t):
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a float: %v", raw, err)
+		}
+		elem.SetFloat(f)
+	case isBool(t):
+		elem.SetBool(parseBool(raw))
+	case isString(t):
+		elem.SetString(raw)
+	default:
+		return fmt.Errorf("unsupported element type %v", t)
+	}
+	return nil
+}
+
+func isStringStringMap(t reflect.Type) bool {
+	return t.Kind() == reflect.Map && t.Key() == reflect.TypeOf("") && t.Elem() == reflect.TypeOf("")
+}
+
+func readEnvMapStringString(field reflect.Value, name, value string) error {
+	m := reflect.MakeMap(field.Type())
+	for _, pair := range strings.Split(value, ",") {
+		k, v, err := splitPair(pair)
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		m.SetMapIndex(reflect.ValueOf(k), reflect.ValueOf(v))
+	}
+	field.Set(m)
+	return nil
+}
+
+func isStringIntMap(t reflect.Type) bool {
+	return t.Kind() == reflect.Map && t.Key() == reflect.TypeOf("") && t.Elem() == reflect.TypeOf(int(0))
+}
+
+func readEnvMapStringInt(field reflect.Value, name, value string) error {
+	m := reflect.MakeMap(field.Type())
+	for _, pair := range strings.Split(value, ",") {
+		k, v, err := splitPair(pair)
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("%s: %q is not a number: %v", name, v, err)
+		}
+		m.SetMapIndex(reflect.ValueOf(k), reflect.ValueOf(n))
+	}
+	field.Set(m)
+	return nil
+}
+
+// splitPair splits a "key=value" pair, trimming whitespace around each side.
+func splitPair(pair string) (key, value string, err error) {
+	parts := strings.SplitN(pair, "=", 2)
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf("invalid key=value pair %q", pair)
 	}
+	return strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]), nil
 }
@@ -1,10 +1,15 @@
 package readenv
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestNotWriteable(t *testing.T) {
@@ -19,6 +24,21 @@ func TestNotWriteable(t *testing.T) {
 	}
 }
 
+func TestUntaggedUnexportedFieldIgnored(t *testing.T) {
+	type testOpts struct {
+		Host  string `env:"HOST"`
+		cache map[string]string
+	}
+	os.Setenv("HOST", "localhost")
+	opts := &testOpts{}
+	if err := ReadEnv(opts); err != nil {
+		t.Errorf("readenv failed: %v", err)
+	}
+	if opts.Host != "localhost" {
+		t.Errorf("Host should have been 'localhost' but was %q", opts.Host)
+	}
+}
+
 func TestFloats(t *testing.T) {
 	type testOpts struct {
 		Float64Type float64 `env:"E_FLOAT64"`
@@ -155,8 +175,9 @@ func TestReadNoTags(t *testing.T) {
 
 func TestReadBadInt(t *testing.T) {
 	type testOpts struct {
-		I int `env:"I"`
+		I int `env:"BAD_INT"`
 	}
+	os.Setenv("BAD_INT", "not-a-number")
 	opts := &testOpts{}
 	if err := ReadEnv(opts); err == nil {
 		t.Error("should have gotten an error but did not")
@@ -165,21 +186,394 @@ func TestReadBadInt(t *testing.T) {
 
 func TestReadBadFloat(t *testing.T) {
 	type testOpts struct {
-		F float64 `env:"F"`
+		F float64 `env:"BAD_FLOAT"`
 	}
+	os.Setenv("BAD_FLOAT", "not-a-float")
 	opts := &testOpts{}
 	if err := ReadEnv(opts); err == nil {
 		t.Error("should have gotten an error but did not")
 	}
 }
 
-func TestReadBadString(t *testing.T) {
+func TestReadUnsetOptional(t *testing.T) {
+	type testOpts struct {
+		S string `env:"UNSET_OPTIONAL_STRING"`
+	}
+	os.Unsetenv("UNSET_OPTIONAL_STRING")
+	opts := &testOpts{}
+	if err := ReadEnv(opts); err != nil {
+		t.Errorf("readenv failed: %v", err)
+	}
+	if opts.S != "" {
+		t.Errorf("S should have been left at its zero value but was %q", opts.S)
+	}
+}
+
+func TestEnvDefault(t *testing.T) {
 	type testOpts struct {
-		S string `env:"S"`
+		Host string `env:"UNSET_HOST" envDefault:"localhost"`
+		Port int    `env:"UNSET_PORT" envDefault:"8080"`
 	}
+	os.Unsetenv("UNSET_HOST")
+	os.Unsetenv("UNSET_PORT")
+	opts := &testOpts{}
+	if err := ReadEnv(opts); err != nil {
+		t.Errorf("readenv failed: %v", err)
+	}
+	if opts.Host != "localhost" {
+		t.Errorf("Host should have defaulted to 'localhost' but was %q", opts.Host)
+	}
+	if opts.Port != 8080 {
+		t.Errorf("Port should have defaulted to 8080 but was %d", opts.Port)
+	}
+}
+
+func TestEnvRequired(t *testing.T) {
+	type testOpts struct {
+		APIKey string `env:"UNSET_API_KEY" envRequired:"true"`
+	}
+	os.Unsetenv("UNSET_API_KEY")
 	opts := &testOpts{}
 	if err := ReadEnv(opts); err == nil {
-		t.Error("should have gotten an error but did not")
+		t.Error("should have gotten an error for a required, unset field")
+	}
+}
+
+func TestParseErrorAggregatesAllFields(t *testing.T) {
+	type testOpts struct {
+		A string `env:"UNSET_A" envRequired:"true"`
+		B string `env:"UNSET_B" envRequired:"true"`
+	}
+	os.Unsetenv("UNSET_A")
+	os.Unsetenv("UNSET_B")
+	opts := &testOpts{}
+	err := ReadEnv(opts)
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("expected a *ParseError, got %T: %v", err, err)
+	}
+	if len(parseErr.Errors) != 2 {
+		t.Fatalf("expected 2 field errors, got %d: %v", len(parseErr.Errors), parseErr.Errors)
+	}
+}
+
+func TestParseErrorUnwrap(t *testing.T) {
+	type testOpts struct {
+		A string `env:"UNSET_UNWRAP_A" envRequired:"true"`
+	}
+	os.Unsetenv("UNSET_UNWRAP_A")
+	opts := &testOpts{}
+	err := ReadEnv(opts)
+	var fieldErr *FieldError
+	if !errors.As(err, &fieldErr) {
+		t.Fatalf("expected errors.As to find a *FieldError, got %T: %v", err, err)
+	}
+	if fieldErr.Field != "A" || fieldErr.Env != "UNSET_UNWRAP_A" {
+		t.Errorf("unexpected FieldError: %+v", fieldErr)
+	}
+}
+
+func TestEnvExpand(t *testing.T) {
+	type testOpts struct {
+		DBHost  string `env:"DB_HOST"`
+		ConnStr string `env:"CONN_STR" envExpand:"true"`
+	}
+	os.Setenv("DB_HOST", "db.internal")
+	os.Setenv("CONN_STR", "postgres://${DB_HOST}:5432/app")
+	opts := &testOpts{}
+	if err := ReadEnv(opts); err != nil {
+		t.Errorf("readenv failed: %v", err)
+	}
+	want := "postgres://db.internal:5432/app"
+	if opts.ConnStr != want {
+		t.Errorf("ConnStr should have been %q but was %q", want, opts.ConnStr)
+	}
+}
+
+func TestEnvFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "password")
+	if err := os.WriteFile(path, []byte("hunter2\n"), 0600); err != nil {
+		t.Fatalf("could not write secret file: %v", err)
+	}
+	type testOpts struct {
+		Password string `env:"DB_PASSWORD" envFile:"true"`
+	}
+	os.Setenv("DB_PASSWORD", path)
+	opts := &testOpts{}
+	if err := ReadEnv(opts); err != nil {
+		t.Errorf("readenv failed: %v", err)
+	}
+	if opts.Password != "hunter2" {
+		t.Errorf("Password should have been 'hunter2' but was %q", opts.Password)
+	}
+}
+
+func TestEnvFileConvention(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "password")
+	if err := os.WriteFile(path, []byte("hunter2\n"), 0600); err != nil {
+		t.Fatalf("could not write secret file: %v", err)
+	}
+	type testOpts struct {
+		Password string `env:"DB_PASSWORD"`
+	}
+	os.Unsetenv("DB_PASSWORD")
+	os.Setenv("DB_PASSWORD_FILE", path)
+	opts := &testOpts{}
+	if err := ReadEnv(opts); err != nil {
+		t.Errorf("readenv failed: %v", err)
+	}
+	if opts.Password != "hunter2" {
+		t.Errorf("Password should have been 'hunter2' but was %q", opts.Password)
+	}
+}
+
+func TestNestedStructs(t *testing.T) {
+	type DBConfig struct {
+		Host string `env:"HOST"`
+		Port int    `env:"PORT"`
+	}
+	type testOpts struct {
+		DB DBConfig `envPrefix:"DB_"`
+	}
+	os.Setenv("DB_HOST", "localhost")
+	os.Setenv("DB_PORT", "5432")
+	opts := &testOpts{}
+	if err := ReadEnv(opts); err != nil {
+		t.Errorf("readenv failed: %v", err)
+	}
+	if opts.DB.Host != "localhost" {
+		t.Errorf("DB.Host should have been 'localhost' but was %s", opts.DB.Host)
+	}
+	if opts.DB.Port != 5432 {
+		t.Errorf("DB.Port should have been 5432 but was %d", opts.DB.Port)
+	}
+}
+
+func TestNestedPointerStruct(t *testing.T) {
+	type RedisConfig struct {
+		Host string `env:"HOST"`
+	}
+	type testOpts struct {
+		Redis *RedisConfig `envPrefix:"REDIS_"`
+	}
+	os.Setenv("REDIS_HOST", "cache.local")
+	opts := &testOpts{}
+	if err := ReadEnv(opts); err != nil {
+		t.Errorf("readenv failed: %v", err)
+	}
+	if opts.Redis == nil {
+		t.Fatal("Redis should have been allocated")
+	}
+	if opts.Redis.Host != "cache.local" {
+		t.Errorf("Redis.Host should have been 'cache.local' but was %s", opts.Redis.Host)
+	}
+}
+
+func TestNestedPointerStructLeftNilWhenUnset(t *testing.T) {
+	type TLSConfig struct {
+		CertFile string `env:"CERT_FILE" envRequired:"true"`
+	}
+	type testOpts struct {
+		TLS *TLSConfig `envPrefix:"TLS_"`
+	}
+	os.Unsetenv("TLS_CERT_FILE")
+	opts := &testOpts{}
+	if err := ReadEnv(opts); err != nil {
+		t.Errorf("readenv failed: %v", err)
+	}
+	if opts.TLS != nil {
+		t.Errorf("TLS should have been left nil, but was %+v", opts.TLS)
+	}
+}
+
+func TestReadEnvTopLevelPrefix(t *testing.T) {
+	type testOpts struct {
+		Host string `env:"HOST"`
+	}
+	os.Setenv("APP_HOST", "example.com")
+	opts := &testOpts{}
+	if err := ReadEnv(opts, "APP_"); err != nil {
+		t.Errorf("readenv failed: %v", err)
+	}
+	if opts.Host != "example.com" {
+		t.Errorf("Host should have been 'example.com' but was %s", opts.Host)
+	}
+}
+
+func TestDuration(t *testing.T) {
+	type testOpts struct {
+		Timeout time.Duration `env:"TIMEOUT"`
+	}
+	os.Setenv("TIMEOUT", "1500ms")
+	opts := &testOpts{}
+	if err := ReadEnv(opts); err != nil {
+		t.Errorf("readenv failed: %v", err)
+	}
+	if opts.Timeout != 1500*time.Millisecond {
+		t.Errorf("Timeout should have been 1500ms but was %s", opts.Timeout)
+	}
+}
+
+func TestTime(t *testing.T) {
+	type testOpts struct {
+		StartsAt time.Time `env:"STARTS_AT"`
+	}
+	os.Setenv("STARTS_AT", "2020-01-02T15:04:05Z")
+	opts := &testOpts{}
+	if err := ReadEnv(opts); err != nil {
+		t.Errorf("readenv failed: %v", err)
+	}
+	want, _ := time.Parse(time.RFC3339, "2020-01-02T15:04:05Z")
+	if !opts.StartsAt.Equal(want) {
+		t.Errorf("StartsAt should have been %s but was %s", want, opts.StartsAt)
+	}
+}
+
+func TestTimeWithLayout(t *testing.T) {
+	type testOpts struct {
+		Day time.Time `env:"DAY" envLayout:"2006-01-02"`
+	}
+	os.Setenv("DAY", "2020-01-02")
+	opts := &testOpts{}
+	if err := ReadEnv(opts); err != nil {
+		t.Errorf("readenv failed: %v", err)
+	}
+	want, _ := time.Parse("2006-01-02", "2020-01-02")
+	if !opts.Day.Equal(want) {
+		t.Errorf("Day should have been %s but was %s", want, opts.Day)
+	}
+}
+
+func TestLocation(t *testing.T) {
+	type testOpts struct {
+		TZ *time.Location `env:"TZ_NAME"`
+	}
+	os.Setenv("TZ_NAME", "UTC")
+	opts := &testOpts{}
+	if err := ReadEnv(opts); err != nil {
+		t.Errorf("readenv failed: %v", err)
+	}
+	if opts.TZ != time.UTC {
+		t.Errorf("TZ should have been UTC but was %v", opts.TZ)
+	}
+}
+
+func TestSlice(t *testing.T) {
+	type testOpts struct {
+		Hosts []string `env:"HOSTS"`
+		Ports []int    `env:"PORTS" envSeparator:":"`
+	}
+	os.Setenv("HOSTS", "a.com, b.com, c.com")
+	os.Setenv("PORTS", "80:443:8080")
+	opts := &testOpts{}
+	if err := ReadEnv(opts); err != nil {
+		t.Errorf("readenv failed: %v", err)
+	}
+	wantHosts := []string{"a.com", "b.com", "c.com"}
+	for i, h := range wantHosts {
+		if opts.Hosts[i] != h {
+			t.Errorf("Hosts[%d] should have been %s but was %s", i, h, opts.Hosts[i])
+		}
+	}
+	wantPorts := []int{80, 443, 8080}
+	for i, p := range wantPorts {
+		if opts.Ports[i] != p {
+			t.Errorf("Ports[%d] should have been %d but was %d", i, p, opts.Ports[i])
+		}
+	}
+}
+
+func TestMap(t *testing.T) {
+	type testOpts struct {
+		Labels map[string]string `env:"LABELS"`
+		Quotas map[string]int    `env:"QUOTAS"`
+	}
+	os.Setenv("LABELS", "env=prod,team=infra")
+	os.Setenv("QUOTAS", "cpu=4,mem=8")
+	opts := &testOpts{}
+	if err := ReadEnv(opts); err != nil {
+		t.Errorf("readenv failed: %v", err)
+	}
+	if opts.Labels["env"] != "prod" || opts.Labels["team"] != "infra" {
+		t.Errorf("Labels was not parsed correctly: %v", opts.Labels)
+	}
+	if opts.Quotas["cpu"] != 4 || opts.Quotas["mem"] != 8 {
+		t.Errorf("Quotas was not parsed correctly: %v", opts.Quotas)
+	}
+}
+
+type upperString string
+
+func (u *upperString) UnmarshalText(text []byte) error {
+	*u = upperString(strings.ToUpper(string(text)))
+	return nil
+}
+
+func (u upperString) MarshalText() ([]byte, error) {
+	return []byte(u), nil
+}
+
+func TestTextMarshaling(t *testing.T) {
+	type testOpts struct {
+		Name upperString `env:"NAME"`
+	}
+	os.Setenv("NAME", "hello")
+	opts := &testOpts{}
+	if err := ReadEnv(opts); err != nil {
+		t.Errorf("readenv failed: %v", err)
+	}
+	if opts.Name != "HELLO" {
+		t.Errorf("Name should have been 'HELLO' but was %q", opts.Name)
+	}
+	out, err := MarshalEnv(opts)
+	if err != nil {
+		t.Fatalf("MarshalEnv failed: %v", err)
+	}
+	if out["NAME"] != "HELLO" {
+		t.Errorf("out[\"NAME\"] should have been \"HELLO\" but was %q", out["NAME"])
+	}
+	roundTripped := &testOpts{}
+	if err := ReadEnvFrom(roundTripped, MapSource(out)); err != nil {
+		t.Fatalf("ReadEnvFrom failed: %v", err)
+	}
+	if *roundTripped != *opts {
+		t.Errorf("round trip should have produced %+v but got %+v", *opts, *roundTripped)
+	}
+}
+
+type csvInts []int
+
+func (c *csvInts) UnmarshalEnv(value string) error {
+	*c = nil
+	for _, part := range strings.Split(value, "|") {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return err
+		}
+		*c = append(*c, n)
+	}
+	return nil
+}
+
+func TestSetter(t *testing.T) {
+	type testOpts struct {
+		Nums csvInts `env:"NUMS"`
+	}
+	os.Setenv("NUMS", "1|2|3")
+	opts := &testOpts{}
+	if err := ReadEnv(opts); err != nil {
+		t.Errorf("readenv failed: %v", err)
+	}
+	want := csvInts{1, 2, 3}
+	if len(opts.Nums) != len(want) {
+		t.Fatalf("Nums should have been %v but was %v", want, opts.Nums)
+	}
+	for i := range want {
+		if opts.Nums[i] != want[i] {
+			t.Errorf("Nums[%d] should have been %d but was %d", i, want[i], opts.Nums[i])
+		}
 	}
 }
 
@@ -0,0 +1,78 @@
+package readenv
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Source is a lookup of environment variables by name. ReadEnv reads from
+// OSSource; ReadEnvFrom accepts any Source, which makes it possible to read
+// configuration from something other than the real process environment.
+type Source interface {
+	// Lookup returns the value of the variable named name, and whether it was
+	// present at all, mirroring the semantics of os.LookupEnv.
+	Lookup(name string) (string, bool)
+}
+
+// OSSource reads from the process environment via os.LookupEnv. It is the
+// Source ReadEnv uses.
+type OSSource struct{}
+
+// Lookup implements Source.
+func (OSSource) Lookup(name string) (string, bool) {
+	return os.LookupEnv(name)
+}
+
+// MapSource reads from an in-memory map, which is useful for building up a
+// test environment without touching real process-wide state via os.Setenv.
+type MapSource map[string]string
+
+// Lookup implements Source.
+func (m MapSource) Lookup(name string) (string, bool) {
+	v, ok := m[name]
+	return v, ok
+}
+
+// NewDotEnvSource reads and parses the .env file at path into a Source.
+func NewDotEnvSource(path string) (MapSource, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseDotEnv(f)
+}
+
+// ParseDotEnv parses the contents of r as a .env file: one KEY=VALUE pair per
+// line, blank lines and lines starting with "#" are ignored, and values may
+// optionally be wrapped in single or double quotes.
+func ParseDotEnv(r io.Reader) (MapSource, error) {
+	src := MapSource{}
+	scanner := bufio.NewScanner(r)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("readenv: invalid .env syntax on line %d: %q", lineNum, line)
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 {
+			if (value[0] == '"' && value[len(value)-1] == '"') || (value[0] == '\'' && value[len(value)-1] == '\'') {
+				value = value[1 : len(value)-1]
+			}
+		}
+		src[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return src, nil
+}
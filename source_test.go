@@ -0,0 +1,62 @@
+package readenv
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadEnvFromMapSource(t *testing.T) {
+	type testOpts struct {
+		Host string `env:"HOST"`
+		Port int    `env:"PORT"`
+	}
+	src := MapSource{
+		"HOST": "db.internal",
+		"PORT": "5432",
+	}
+	opts := &testOpts{}
+	if err := ReadEnvFrom(opts, src); err != nil {
+		t.Errorf("readenv failed: %v", err)
+	}
+	if opts.Host != "db.internal" {
+		t.Errorf("Host should have been 'db.internal' but was %s", opts.Host)
+	}
+	if opts.Port != 5432 {
+		t.Errorf("Port should have been 5432 but was %d", opts.Port)
+	}
+}
+
+func TestParseDotEnv(t *testing.T) {
+	const dotenv = `
+# a comment
+export HOST=db.internal
+PORT=5432
+NAME="my app"
+EMPTY=
+`
+	src, err := ParseDotEnv(strings.NewReader(dotenv))
+	if err != nil {
+		t.Fatalf("ParseDotEnv failed: %v", err)
+	}
+	if src["HOST"] != "db.internal" {
+		t.Errorf("HOST should have been 'db.internal' but was %q", src["HOST"])
+	}
+	if src["PORT"] != "5432" {
+		t.Errorf("PORT should have been '5432' but was %q", src["PORT"])
+	}
+	if src["NAME"] != "my app" {
+		t.Errorf("NAME should have been 'my app' but was %q", src["NAME"])
+	}
+	if v, ok := src.Lookup("EMPTY"); !ok || v != "" {
+		t.Errorf("EMPTY should have been present and empty, got %q, %v", v, ok)
+	}
+	if _, ok := src.Lookup("MISSING"); ok {
+		t.Error("MISSING should not have been present")
+	}
+}
+
+func TestParseDotEnvInvalidLine(t *testing.T) {
+	if _, err := ParseDotEnv(strings.NewReader("not-a-valid-line")); err == nil {
+		t.Error("should have gotten an error for a line without '='")
+	}
+}